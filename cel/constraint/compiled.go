@@ -0,0 +1,157 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package constraint
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+// Compiled is a constraint tree that has already been compiled against an
+// Env and is ready for repeated evaluation.
+type Compiled struct {
+	root compiledNode
+}
+
+// Eval evaluates the compiled constraint tree against input (an activation,
+// typically a map[string]interface{}), walking the tree once and
+// short-circuiting the same way And/Or do, so that evaluation reports
+// exactly which sub-constraints were actually checked.
+func (c *Compiled) Eval(input interface{}) (*Result, error) {
+	result := &Result{Satisfied: true}
+	satisfied, err := c.root.eval(input, result)
+	if err != nil {
+		return nil, err
+	}
+	// The returned bool, not result.Satisfied, is authoritative: a composite
+	// node such as notNode can be unsatisfied without any leaf beneath it
+	// having called result.fail, since its own failure is a negation of a
+	// child that itself succeeded.
+	result.Satisfied = satisfied
+	return result, nil
+}
+
+// compiledNode is one node of a compiled constraint tree. eval reports
+// whether the subtree rooted at this node is satisfied, recording any
+// failing leaves into result.
+type compiledNode interface {
+	eval(input interface{}, result *Result) (bool, error)
+}
+
+// leafNode evaluates a single compiled CEL program and records its own
+// message into result when it fails.
+type leafNode struct {
+	path       string
+	prg        cel.Program
+	constraint *CelConstraint
+}
+
+func (l *leafNode) eval(input interface{}, result *Result) (bool, error) {
+	out, _, err := l.prg.Eval(input)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", l.path, err)
+	}
+	b, ok := out.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("%s: rule did not evaluate to a bool, got %v", l.path, out.Type())
+	}
+	satisfied := b == types.True
+	if !satisfied {
+		message := l.constraint.Message
+		if l.constraint.FailureMessage != "" {
+			message = l.constraint.FailureMessage
+		}
+		result.fail(l.path, message)
+	}
+	return satisfied, nil
+}
+
+// allNode requires every child to be satisfied, short-circuiting on the
+// first failure like And.
+type allNode struct {
+	children []compiledNode
+}
+
+// allNode and anyNode rely entirely on the leaf nodes beneath them to record
+// FailedPath/Messages entries, since a composite node has no CEL rule of its
+// own to evaluate or report on. notNode is the exception: a failing `not` has
+// no failing leaf beneath it (the leaf it negates succeeded), so it records
+// its own entry -- see notNode.eval below.
+
+func (n *allNode) eval(input interface{}, result *Result) (bool, error) {
+	for _, child := range n.children {
+		satisfied, err := child.eval(input, result)
+		if err != nil {
+			return false, err
+		}
+		if !satisfied {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// anyNode requires at least one child to be satisfied, short-circuiting on
+// the first success like Or. Failures of children visited before a
+// satisfying one are discarded from result once a child succeeds; they are
+// kept only when every child fails.
+type anyNode struct {
+	children []compiledNode
+}
+
+func (n *anyNode) eval(input interface{}, result *Result) (bool, error) {
+	satisfiedMark, failedPathLen := result.mark()
+	for _, child := range n.children {
+		satisfied, err := child.eval(input, result)
+		if err != nil {
+			return false, err
+		}
+		if satisfied {
+			// A later child succeeding means the any node as a whole is
+			// satisfied, so the failures earlier children recorded against
+			// themselves are not failures of the overall tree; restore the
+			// result to how it looked before this node ran.
+			result.reset(satisfiedMark, failedPathLen)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// notNode requires its child to be unsatisfied.
+type notNode struct {
+	path  string
+	child compiledNode
+}
+
+func (n *notNode) eval(input interface{}, result *Result) (bool, error) {
+	// The child's own fail() calls describe what it found true; under `not`,
+	// that's the success case, so discard any failure it recorded for itself
+	// -- restoring Satisfied along with FailedPath/Messages -- before
+	// deciding whether *this* node's own negation failed.
+	satisfiedMark, failedPathLen := result.mark()
+	childSatisfied, err := n.child.eval(input, result)
+	if err != nil {
+		return false, err
+	}
+	result.reset(satisfiedMark, failedPathLen)
+	satisfied := !childSatisfied
+	if !satisfied {
+		result.fail(n.path, "not: negated constraint was satisfied")
+	}
+	return satisfied, nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package constraint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// fakeProgram is a cel.Program stand-in that always returns a fixed result,
+// so these tests can exercise the compiledNode tree's all/any/not
+// combinators directly, without depending on a real CEL compile-and-plan
+// pipeline.
+type fakeProgram struct {
+	val ref.Val
+}
+
+func (p *fakeProgram) Eval(input interface{}) (ref.Val, *cel.EvalDetails, error) {
+	return p.val, nil, nil
+}
+
+// leaf builds a leafNode that reports satisfied without compiling any real
+// CEL, labeling its Messages/FailureMessage entry with path for assertions.
+func leaf(path string, satisfied bool) *leafNode {
+	return &leafNode{
+		path: path,
+		prg:  &fakeProgram{val: types.Bool(satisfied)},
+		constraint: &CelConstraint{
+			Message:        path + ": message",
+			FailureMessage: path + ": failure message",
+		},
+	}
+}
+
+func TestAllNodeEval(t *testing.T) {
+	tests := []struct {
+		name           string
+		children       []compiledNode
+		wantSatisfied  bool
+		wantFailedPath []string
+	}{
+		{
+			name:          "all satisfied",
+			children:      []compiledNode{leaf("a", true), leaf("b", true)},
+			wantSatisfied: true,
+		},
+		{
+			name:           "short-circuits on first failure",
+			children:       []compiledNode{leaf("a", false), leaf("b", false)},
+			wantSatisfied:  false,
+			wantFailedPath: []string{"a"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := &Result{Satisfied: true}
+			n := &allNode{children: tc.children}
+			satisfied, err := n.eval(nil, result)
+			if err != nil {
+				t.Fatalf("eval() returned error: %v", err)
+			}
+			if satisfied != tc.wantSatisfied {
+				t.Errorf("eval() = %v, want %v", satisfied, tc.wantSatisfied)
+			}
+			if !reflect.DeepEqual(result.FailedPath, tc.wantFailedPath) {
+				t.Errorf("FailedPath = %v, want %v", result.FailedPath, tc.wantFailedPath)
+			}
+		})
+	}
+}
+
+func TestAnyNodeEval(t *testing.T) {
+	tests := []struct {
+		name           string
+		children       []compiledNode
+		wantSatisfied  bool
+		wantFailedPath []string
+	}{
+		{
+			name:          "first child succeeds",
+			children:      []compiledNode{leaf("a", true), leaf("b", false)},
+			wantSatisfied: true,
+		},
+		{
+			name:          "later child succeeds, earlier failure discarded",
+			children:      []compiledNode{leaf("a", false), leaf("b", true)},
+			wantSatisfied: true,
+		},
+		{
+			name:           "all children fail, failures kept for diagnostics",
+			children:       []compiledNode{leaf("a", false), leaf("b", false)},
+			wantSatisfied:  false,
+			wantFailedPath: []string{"a", "b"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := &Result{Satisfied: true}
+			n := &anyNode{children: tc.children}
+			satisfied, err := n.eval(nil, result)
+			if err != nil {
+				t.Fatalf("eval() returned error: %v", err)
+			}
+			if satisfied != tc.wantSatisfied {
+				t.Errorf("eval() = %v, want %v", satisfied, tc.wantSatisfied)
+			}
+			if !reflect.DeepEqual(result.FailedPath, tc.wantFailedPath) {
+				t.Errorf("FailedPath = %v, want %v", result.FailedPath, tc.wantFailedPath)
+			}
+		})
+	}
+}
+
+func TestNotNodeEval(t *testing.T) {
+	tests := []struct {
+		name          string
+		child         compiledNode
+		wantSatisfied bool
+	}{
+		{
+			name:          "negates a satisfied child",
+			child:         leaf("a", true),
+			wantSatisfied: false,
+		},
+		{
+			name:          "negates an unsatisfied child",
+			child:         leaf("a", false),
+			wantSatisfied: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := &Result{Satisfied: true}
+			n := &notNode{path: "not", child: tc.child}
+			satisfied, err := n.eval(nil, result)
+			if err != nil {
+				t.Fatalf("eval() returned error: %v", err)
+			}
+			if satisfied != tc.wantSatisfied {
+				t.Errorf("eval() = %v, want %v", satisfied, tc.wantSatisfied)
+			}
+			// Either way, the child's own failure/success is never visible
+			// in the result -- only notNode's own fail() entry, if any.
+			if tc.wantSatisfied && len(result.FailedPath) != 0 {
+				t.Errorf("FailedPath = %v, want empty", result.FailedPath)
+			}
+			if !tc.wantSatisfied && !reflect.DeepEqual(result.FailedPath, []string{"not"}) {
+				t.Errorf("FailedPath = %v, want [not]", result.FailedPath)
+			}
+		})
+	}
+}
+
+// TestCompiledEvalNotRegression guards against the bug where a failing `not`
+// never marked the overall Result unsatisfied: Compiled.Eval read only
+// result.Satisfied, which nothing had set to false since notNode discarded
+// its child's failure and recorded none of its own.
+func TestCompiledEvalNotRegression(t *testing.T) {
+	c := &Compiled{root: &notNode{path: "not", child: leaf("a", true)}}
+	result, err := c.Eval(nil)
+	if err != nil {
+		t.Fatalf("Eval() returned error: %v", err)
+	}
+	if result.Satisfied {
+		t.Fatalf("Satisfied = true, want false for not(<satisfied leaf>)")
+	}
+}
+
+// TestCompiledEvalAllOfNotRegression covers the same defect nested one level
+// deeper, as all:[{not:{cel:"true"}}] exercised it in the bug report.
+func TestCompiledEvalAllOfNotRegression(t *testing.T) {
+	c := &Compiled{root: &allNode{children: []compiledNode{
+		&notNode{path: "all[0].not", child: leaf("a", true)},
+	}}}
+	result, err := c.Eval(nil)
+	if err != nil {
+		t.Fatalf("Eval() returned error: %v", err)
+	}
+	if result.Satisfied {
+		t.Fatalf("Satisfied = true, want false for all:[not(<satisfied leaf>)]")
+	}
+}
+
+func TestCompiledEvalRecordsFailedPathAndMessages(t *testing.T) {
+	c := &Compiled{root: &allNode{children: []compiledNode{
+		leaf("all[0]", true),
+		leaf("all[1]", false),
+	}}}
+	result, err := c.Eval(nil)
+	if err != nil {
+		t.Fatalf("Eval() returned error: %v", err)
+	}
+	if result.Satisfied {
+		t.Fatalf("Satisfied = true, want false")
+	}
+	wantFailedPath := []string{"all[1]"}
+	if !reflect.DeepEqual(result.FailedPath, wantFailedPath) {
+		t.Errorf("FailedPath = %v, want %v", result.FailedPath, wantFailedPath)
+	}
+	wantMessages := []string{"all[1]: failure message"}
+	if !reflect.DeepEqual(result.Messages, wantMessages) {
+		t.Errorf("Messages = %v, want %v", result.Messages, wantMessages)
+	}
+}
+
+var _ cel.Program = (*fakeProgram)(nil)
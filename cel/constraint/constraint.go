@@ -0,0 +1,81 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package constraint lets callers declare compound boolean constraints as
+// structured data (typically unmarshaled from JSON or YAML) and compile them
+// into a single evaluable form, rather than hand-authoring one large CEL
+// expression. It is comparable to what OLM's olm.constraint dependency type
+// provides for bundle validation.
+package constraint
+
+import "fmt"
+
+// Constraint is a discriminated union: exactly one of Cel, All, Any, or Not
+// should be set. It is the shape callers unmarshal JSON/YAML into.
+type Constraint struct {
+	// Cel is a leaf constraint: a raw CEL expression plus optional messages.
+	Cel *CelConstraint `json:"cel,omitempty" yaml:"cel,omitempty"`
+
+	// All requires every child constraint to be satisfied.
+	All []Constraint `json:"all,omitempty" yaml:"all,omitempty"`
+
+	// Any requires at least one child constraint to be satisfied.
+	Any []Constraint `json:"any,omitempty" yaml:"any,omitempty"`
+
+	// Not requires the child constraint to be unsatisfied.
+	Not *Constraint `json:"not,omitempty" yaml:"not,omitempty"`
+}
+
+// CelConstraint is a leaf node: a single boolean CEL expression and the
+// messages to surface when it is, or is not, satisfied.
+type CelConstraint struct {
+	// Rule is the raw CEL expression, which must evaluate to a bool.
+	Rule string `json:"rule" yaml:"rule"`
+
+	// Message describes what this leaf checks; it is the leaf's entry in a
+	// Result's Messages when the rule evaluates to false and FailureMessage is
+	// not set.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+
+	// FailureMessage, if set, is reported in place of Message when the rule
+	// evaluates to false.
+	FailureMessage string `json:"failureMessage,omitempty" yaml:"failureMessage,omitempty"`
+}
+
+// kind identifies which arm of the Constraint union is populated, so that
+// validation and compilation don't need to repeat the same nil checks.
+func (c *Constraint) kind() (string, error) {
+	set := 0
+	kind := ""
+	if c.Cel != nil {
+		set++
+		kind = "cel"
+	}
+	if c.All != nil {
+		set++
+		kind = "all"
+	}
+	if c.Any != nil {
+		set++
+		kind = "any"
+	}
+	if c.Not != nil {
+		set++
+		kind = "not"
+	}
+	if set != 1 {
+		return "", fmt.Errorf("constraint must set exactly one of cel, all, any, not; got %d", set)
+	}
+	return kind, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package constraint
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Env wraps the existing cel.Env (already provided by the cel package) so
+// that every leaf of a constraint tree compiles against the same shared
+// variable declarations, rather than each leaf needing its own cel.Env.
+type Env struct {
+	celEnv *cel.Env
+}
+
+// NewEnv creates an Env whose leaves share the variable and function
+// declarations configured via opts, exactly as they would for a single
+// hand-authored CEL expression.
+func NewEnv(opts ...cel.EnvOption) (*Env, error) {
+	celEnv, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Env{celEnv: celEnv}, nil
+}
+
+// Compile recursively compiles every `cel` leaf in c against e's shared
+// declarations and returns a Compiled constraint ready for repeated
+// evaluation. Compilation fails on the first leaf that fails to parse or
+// type-check, or if the tree is malformed (e.g. a node sets more than one of
+// cel/all/any/not).
+func (e *Env) Compile(c Constraint) (*Compiled, error) {
+	node, err := e.compileNode(c, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Compiled{root: node}, nil
+}
+
+// compileNode compiles c and its children, labeling each leaf with a
+// dotted path (e.g. "all[0].any[1]") used later to populate
+// Result.FailedPath.
+func (e *Env) compileNode(c Constraint, path string) (compiledNode, error) {
+	kind, err := c.kind()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", orRoot(path), err)
+	}
+	switch kind {
+	case "cel":
+		ast, issues := e.celEnv.Compile(c.Cel.Rule)
+		if issues.Err() != nil {
+			return nil, fmt.Errorf("%s: %w", orRoot(path), issues.Err())
+		}
+		prg, err := e.celEnv.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", orRoot(path), err)
+		}
+		return &leafNode{path: orRoot(path), prg: prg, constraint: c.Cel}, nil
+
+	case "all":
+		children := make([]compiledNode, len(c.All))
+		for i, child := range c.All {
+			children[i], err = e.compileNode(child, fmt.Sprintf("%sall[%d]", prefix(path), i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &allNode{children: children}, nil
+
+	case "any":
+		children := make([]compiledNode, len(c.Any))
+		for i, child := range c.Any {
+			children[i], err = e.compileNode(child, fmt.Sprintf("%sany[%d]", prefix(path), i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &anyNode{children: children}, nil
+
+	case "not":
+		notPath := fmt.Sprintf("%snot", prefix(path))
+		child, err := e.compileNode(*c.Not, notPath)
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{path: orRoot(notPath), child: child}, nil
+	}
+	return nil, fmt.Errorf("%s: unreachable constraint kind %q", orRoot(path), kind)
+}
+
+func prefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + "."
+}
+
+func orRoot(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
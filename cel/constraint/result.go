@@ -0,0 +1,60 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package constraint
+
+// Result is the outcome of evaluating a compiled constraint tree.
+type Result struct {
+	// Satisfied reports whether the overall constraint tree passed.
+	Satisfied bool
+
+	// FailedPath names the specific leaf constraints, and any failing `not`
+	// nodes, in tree order, that caused Satisfied to be false. Evaluation short-circuits the same way
+	// And/Or do, so this is not necessarily every failing leaf in the tree --
+	// only the ones actually visited.
+	FailedPath []string
+
+	// Messages holds, in FailedPath order, the message (or FailureMessage, if
+	// set) for each entry in FailedPath.
+	Messages []string
+}
+
+// fail appends a failing leaf's (or `not` node's) path and message to the
+// result and reports unsatisfied.
+func (r *Result) fail(path string, message string) {
+	r.Satisfied = false
+	r.FailedPath = append(r.FailedPath, path)
+	r.Messages = append(r.Messages, message)
+}
+
+// mark snapshots Satisfied and the length of FailedPath/Messages, for a
+// composite node (any, not) to restore via reset once it knows whether a
+// child's failure should count against the overall result.
+func (r *Result) mark() (satisfied bool, failedPathLen int) {
+	return r.Satisfied, len(r.FailedPath)
+}
+
+// reset restores Satisfied and truncates FailedPath/Messages back to the
+// values captured by mark, discarding anything a child recorded since.
+func (r *Result) reset(satisfied bool, failedPathLen int) {
+	r.Satisfied = satisfied
+	if failedPathLen == 0 {
+		// Nothing was recorded at mark time: go back to nil, exactly as a
+		// Result that never had a failure recorded.
+		r.FailedPath, r.Messages = nil, nil
+		return
+	}
+	r.FailedPath = r.FailedPath[:failedPathLen]
+	r.Messages = r.Messages[:failedPathLen]
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel
+
+import "fmt"
+
+// EnvOption configures an Env at construction time.
+type EnvOption func(e *Env) (*Env, error)
+
+// Env is the compilation environment expressions are checked and compiled
+// against: the set of variable and function declarations visible to
+// Env.Compile.
+type Env struct {
+	decls map[string]*decl
+}
+
+type decl struct {
+	name string
+}
+
+// NewEnv creates an Env with the given options applied in order.
+func NewEnv(opts ...EnvOption) (*Env, error) {
+	e := &Env{decls: map[string]*decl{}}
+	var err error
+	for _, opt := range opts {
+		e, err = opt(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Ast is a type-checked CEL expression, ready to be planned into a Program
+// via Env.Program.
+type Ast struct {
+	source string
+}
+
+// Issues reports the errors, if any, found while compiling an expression.
+type Issues struct {
+	errs []error
+}
+
+// Err returns a single error summarizing all issues, or nil if there were
+// none.
+func (i *Issues) Err() error {
+	if i == nil || len(i.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v", i.errs)
+}
+
+// Compile returns an Ast holding expr, or Issues when expr is empty. It does
+// not parse expr or type-check it against e's declarations.
+func (e *Env) Compile(expr string) (*Ast, *Issues) {
+	if expr == "" {
+		return nil, &Issues{errs: []error{fmt.Errorf("empty expression")}}
+	}
+	return &Ast{source: expr}, nil
+}
+
+// Program returns a Program configured by opts such as WithTracer. ast is not
+// read; the returned Program's Eval does not evaluate it.
+func (e *Env) Program(ast *Ast, opts ...ProgramOption) (Program, error) {
+	return newProgram(opts...)
+}
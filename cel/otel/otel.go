@@ -0,0 +1,93 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otel adapts cel.EvalTracer onto go.opentelemetry.io/otel, so that a
+// Program built with cel.WithTracer(otel.NewTracer(...)) reports spans and
+// events to whatever OpenTelemetry exporter the host process has configured.
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/google/cel-go/cel"
+)
+
+// tracer adapts a trace.Tracer to cel.EvalTracer.
+type tracer struct {
+	tr trace.Tracer
+}
+
+// NewTracer returns a cel.EvalTracer that reports spans through tr, the
+// OpenTelemetry tracer obtained from the host process's TracerProvider, e.g.
+// `otel.Tracer("cel-go")`.
+func NewTracer(tr trace.Tracer) cel.EvalTracer {
+	return &tracer{tr: tr}
+}
+
+// StartSpan implements cel.EvalTracer.
+func (t *tracer) StartSpan(ctx context.Context, name string) (context.Context, cel.Span) {
+	spanCtx, sp := t.tr.Start(ctx, name)
+	return spanCtx, &span{sp: sp}
+}
+
+// Event implements cel.EvalTracer, recording a point-in-time occurrence (such
+// as a single comprehension step or list scan) against the span already
+// present on ctx, if any.
+func (t *tracer) Event(ctx context.Context, name string, attrs ...cel.Attribute) {
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(toOtelAttrs(attrs)...))
+}
+
+// span adapts an OpenTelemetry trace.Span to cel.Span.
+type span struct {
+	sp trace.Span
+}
+
+// End implements cel.Span, attaching any summary attributes (expression ID,
+// iteration count) before ending the underlying OpenTelemetry span.
+func (s *span) End(attrs ...cel.Attribute) {
+	if len(attrs) > 0 {
+		s.sp.SetAttributes(toOtelAttrs(attrs)...)
+	}
+	s.sp.End()
+}
+
+// toOtelAttrs converts cel.Attribute values, whose Value is an arbitrary Go
+// value, into the concrete attribute.KeyValue types OpenTelemetry requires.
+func toOtelAttrs(attrs []cel.Attribute) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			out = append(out, attribute.String(a.Key, v))
+		case bool:
+			out = append(out, attribute.Bool(a.Key, v))
+		case int:
+			out = append(out, attribute.Int(a.Key, v))
+		case int64:
+			out = append(out, attribute.Int64(a.Key, v))
+		case float64:
+			out = append(out, attribute.Float64(a.Key, v))
+		default:
+			// Covers attribute values without a dedicated attribute.KeyValue
+			// constructor, such as a ref.Val result, so instrumentation never
+			// silently drops an attribute because of its Go type.
+			out = append(out, attribute.String(a.Key, fmt.Sprintf("%v", v)))
+		}
+	}
+	return out
+}
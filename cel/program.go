@@ -0,0 +1,106 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel
+
+import (
+	"context"
+	"reflect"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Program is a compiled CEL expression that can be evaluated repeatedly
+// against different activations.
+type Program interface {
+	// Eval evaluates the compiled expression against the given input,
+	// returning the result, optional evaluation details, and any error
+	// encountered.
+	Eval(input interface{}) (ref.Val, *EvalDetails, error)
+}
+
+// EvalDetails carries optional, non-result information about a single
+// Program.Eval call.
+type EvalDetails struct{}
+
+// ProgramOption configures a Program at construction time, in the same style
+// as the rest of this package's functional options.
+type ProgramOption func(p *prog) (*prog, error)
+
+// prog is the default Program implementation. The fields relevant to
+// evaluation planning (interpretable, decorators, and so on) live alongside
+// this one in the rest of the package; only the tracing-related fields are
+// declared here, since WithTracer/WithTracingContext are the only options
+// this trimmed Program supports.
+type prog struct {
+	tracer   EvalTracer
+	traceCtx context.Context
+}
+
+// newProgram applies opts over a fresh prog, defaulting the tracer to a
+// no-op so that Eval never needs to nil-check it.
+func newProgram(opts ...ProgramOption) (*prog, error) {
+	p := &prog{tracer: NoopTracer(), traceCtx: context.Background()}
+	var err error
+	for _, opt := range opts {
+		p, err = opt(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Eval implements the Program interface. It wraps the underlying evaluation
+// in a top-level span so that operators running CEL in policy engines get
+// the same tracing visibility they already have for the surrounding RPCs.
+//
+// The interpretable-planning half of this method (turning the compiled AST
+// plus input activation into a ref.Val) lives alongside the rest of the
+// non-tracing Program implementation; this method only adds the span around
+// that call.
+func (p *prog) Eval(input interface{}) (ref.Val, *EvalDetails, error) {
+	_, span := p.tracer.StartSpan(p.traceCtx, "cel.Program.Eval")
+	defer span.End()
+	return p.evalWithTracing(input)
+}
+
+// nativeToValue adapts a native Go/protoreflect value encountered while
+// evaluating input into a ref.Val, threading this Program's configured
+// tracer and traceCtx through to types.NativeToValue so that list-typed
+// fields come back instrumented whenever WithTracer has been set, and
+// undecorated on the common, tracer-less path.
+func (p *prog) nativeToValue(adapter ref.TypeAdapter, goType reflect.Type, desc protoreflect.FieldDescriptor, value interface{}) (ref.Val, bool) {
+	tracer := p.tracer
+	if tracer == NoopTracer() {
+		// No tracer configured: pass nil so the list is left undecorated.
+		tracer = nil
+	}
+	return types.NativeToValue(p.traceCtx, tracer, adapter, goType, desc, value)
+}
+
+// evalWithTracing performs the actual evaluation. It is factored out of Eval
+// so the tracing span in Eval always covers the full call, including any
+// panics recovered further down the real interpreter stack.
+//
+// The interpreter that walks the compiled expression's activation and calls
+// p.nativeToValue on each native field it encounters lives outside this
+// trimmed Program; until it is wired in, Eval's own top-level span is the
+// only span the tracer/traceCtx fields above produce.
+func (p *prog) evalWithTracing(input interface{}) (ref.Val, *EvalDetails, error) {
+	return nil, nil, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel
+
+import (
+	"context"
+
+	"github.com/google/cel-go/common/types"
+)
+
+// EvalTracer is the interface a Program's evaluation is instrumented through.
+// It is an alias of types.Tracer so that the low-level hot paths in
+// common/types (list/map traversal, comprehension steps) and the top-level
+// Program.Eval entry point share a single implementation without
+// common/types depending on this package.
+type EvalTracer = types.Tracer
+
+// Span is the handle returned by an EvalTracer's StartSpan, used to end the
+// span and attach summary attributes to it.
+type Span = types.Span
+
+// Attribute is a single key/value pair attached to a span or event.
+type Attribute = types.Attribute
+
+// NoopTracer returns an EvalTracer whose methods are all no-ops. It is the
+// default used by a Program that has not been configured with WithTracer.
+func NoopTracer() EvalTracer {
+	return types.NoopTracer()
+}
+
+// WithTracer configures the EvalTracer used to instrument Program.Eval and
+// the list traversal hot paths in common/types (Contains, Equal, Iterator,
+// Add) for any Program built from this option set. Map traversal is not
+// instrumented.
+func WithTracer(tracer EvalTracer) ProgramOption {
+	return func(p *prog) (*prog, error) {
+		if tracer == nil {
+			tracer = NoopTracer()
+		}
+		p.tracer = tracer
+		return p, nil
+	}
+}
+
+// WithTracingContext supplies the context.Context a tracer should derive its
+// spans from, so that a request-scoped context passed in from an HTTP or gRPC
+// handler becomes the parent of the spans Program.Eval emits. It has no
+// effect unless WithTracer has also been set.
+func WithTracingContext(ctx context.Context) ProgramOption {
+	return func(p *prog) (*prog, error) {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		p.traceCtx = ctx
+		return p, nil
+	}
+}
@@ -15,8 +15,10 @@
 package types
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sync"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
@@ -33,6 +35,20 @@ var (
 type jsonListValue struct {
 	*structpb.ListValue
 	ref.TypeAdapter
+	// elemsMu is a read-mostly lock over elems: a jsonListValue can outlive a
+	// single Program.Eval call (e.g. a message field adapted once and reused
+	// across concurrent evaluations), so the lazy cache below needs to stay
+	// safe for concurrent Get/Contains/Iterator calls the same way the
+	// uncached, purely functional Get used to be. Every element read takes
+	// only the read lock; only the (at most once per index) populating write
+	// takes the write lock, so a hot Fold/Contains scan over an
+	// already-cached list never contends with concurrent readers.
+	elemsMu sync.RWMutex
+	// elems lazily caches the ref.Val conversion of each element, populated on
+	// first access and bounded by len(GetValues()), so that repeated access to
+	// the same index (e.g. inside a nested comprehension) does not allocate a
+	// fresh wrapper every time.
+	elems []ref.Val
 }
 
 // NewJSONList creates a traits.Lister implementation backed by a JSON list that has been encoded
@@ -43,6 +59,15 @@ func NewJSONList(adapter ref.TypeAdapter, l *structpb.ListValue) traits.Lister {
 	return &jsonListValue{TypeAdapter: adapter, ListValue: l}
 }
 
+// NewTracedJSONList creates a traits.Lister backed by a JSON list, the same
+// as NewJSONList, with its Contains, Equal, Iterator, and Add calls
+// instrumented through tracer. It always wraps the list, using a no-op tracer
+// when tracer is nil; NativeToValue does not call it, and wraps lists itself
+// through NewTracedList.
+func NewTracedJSONList(ctx context.Context, tracer Tracer, adapter ref.TypeAdapter, l *structpb.ListValue) traits.Lister {
+	return NewTracedList(ctx, tracer, NewJSONList(adapter, l))
+}
+
 // Add implements the traits.Adder interface method.
 func (l *jsonListValue) Add(other ref.Val) ref.Val {
 	if other.Type() != ListType {
@@ -66,8 +91,8 @@ func (l *jsonListValue) Contains(elem ref.Val) ref.Val {
 		return elem
 	}
 	var err ref.Val
-	for i := Int(0); i < l.Size().(Int); i++ {
-		val := l.Get(i)
+	found := false
+	l.Fold(func(val ref.Val) bool {
 		cmp := elem.Equal(val)
 		b, ok := cmp.(Bool)
 		// When there is an error on the contain check, this is not necessarily terminal.
@@ -78,8 +103,13 @@ func (l *jsonListValue) Contains(elem ref.Val) ref.Val {
 			err = ValOrErr(cmp, "no such overload")
 		}
 		if b == True {
-			return True
+			found = true
+			return false
 		}
+		return true
+	})
+	if found {
+		return True
 	}
 	if err != nil {
 		return err
@@ -87,6 +117,20 @@ func (l *jsonListValue) Contains(elem ref.Val) ref.Val {
 	return False
 }
 
+// Fold implements a traits.Foldable-style fast path used by Contains: it walks
+// the cached elements once, without a Size().(Int) cast on every iteration.
+// Iterator does not call Fold; it reads the same element cache through get.
+// f returns false to stop the walk early, the same way Contains stops as soon
+// as it finds a match.
+func (l *jsonListValue) Fold(f func(ref.Val) bool) {
+	n := len(l.GetValues())
+	for i := 0; i < n; i++ {
+		if !f(l.get(i)) {
+			return
+		}
+	}
+}
+
 // ConvertToNative implements the ref.Val interface method.
 func (l *jsonListValue) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
 	switch typeDesc.Kind() {
@@ -165,16 +209,45 @@ func (l *jsonListValue) Get(index ref.Val) ref.Val {
 	if i < 0 || i >= l.Size().(Int) {
 		return NewErr("index '%d' out of range in list size '%d'", i, l.Size())
 	}
-	elem := l.GetValues()[i]
-	return l.NativeToValue(elem)
+	return l.get(int(i))
+}
+
+// get converts the i'th element to a ref.Val, populating l.elems lazily on
+// first access, without an index bounds check -- callers must have already
+// established that i is in range.
+//
+// The common case -- every element already cached, as on the second and
+// later pass of a repeated Contains/Fold scan -- only ever takes the read
+// lock below, so concurrent scans of an already-populated list don't
+// serialize on elemsMu the way a plain sync.Mutex taken once per element
+// would. Only the first access to a given index takes the write lock, to
+// populate it.
+func (l *jsonListValue) get(i int) ref.Val {
+	l.elemsMu.RLock()
+	if l.elems != nil {
+		if v := l.elems[i]; v != nil {
+			l.elemsMu.RUnlock()
+			return v
+		}
+	}
+	l.elemsMu.RUnlock()
+
+	l.elemsMu.Lock()
+	defer l.elemsMu.Unlock()
+	if l.elems == nil {
+		l.elems = make([]ref.Val, len(l.GetValues()))
+	}
+	if l.elems[i] == nil {
+		l.elems[i] = l.NativeToValue(l.GetValues()[i])
+	}
+	return l.elems[i]
 }
 
 // Iterator implements the traits.Iterable interface method.
 func (l *jsonListValue) Iterator() traits.Iterator {
 	return &jsonValueListIterator{
 		baseIterator: &baseIterator{},
-		TypeAdapter:  l.TypeAdapter,
-		elems:        l.GetValues(),
+		list:         l,
 		len:          len(l.GetValues())}
 }
 
@@ -195,9 +268,8 @@ func (l *jsonListValue) Value() interface{} {
 
 type jsonValueListIterator struct {
 	*baseIterator
-	ref.TypeAdapter
+	list   *jsonListValue
 	cursor int
-	elems  []*structpb.Value
 	len    int
 }
 
@@ -211,7 +283,7 @@ func (it *jsonValueListIterator) Next() ref.Val {
 	if it.HasNext() == True {
 		index := it.cursor
 		it.cursor++
-		return it.NativeToValue(it.elems[index])
+		return it.list.get(index)
 	}
 	return nil
 }
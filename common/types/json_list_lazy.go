@@ -0,0 +1,251 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"sync"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// JSONListProducer supplies the JSON value at index i of a lazily-streamed
+// list, e.g. values read one at a time off a json.Decoder. It is called at
+// most once per index.
+type JSONListProducer func(i int) *structpb.Value
+
+// jsonListLazyValue is a traits.Lister backed by a JSONListProducer rather
+// than a fully materialized *structpb.ListValue, so that scanning the list
+// (Contains, Iterator, Fold) never requires every element to have been read
+// off the wire before CEL can begin evaluating it.
+type jsonListLazyValue struct {
+	ref.TypeAdapter
+	size     int
+	producer JSONListProducer
+	// elemsMu is the same read-mostly lock as jsonListValue's elemsMu: reads
+	// of an already-populated index only need the read lock, so concurrent
+	// callers sharing this list don't serialize with each other, only with
+	// the (at most once per index) write that populates the cache.
+	elemsMu sync.RWMutex
+	elems   []ref.Val
+	// raw holds each produced JSON value, so that materialize reuses it
+	// instead of calling producer a second time.
+	raw []*structpb.Value
+}
+
+// NewJSONListLazy creates a traits.Lister implementation backed by a producer
+// function instead of a pre-materialized *structpb.ListValue, for callers
+// streaming JSON off the wire who already know the list's length but want to
+// avoid allocating every element up front.
+//
+// The `adapter` argument provides type adaptation capabilities from proto to
+// CEL. `size` is the list's length; `producer` is invoked lazily, at most
+// once per index, the first time that index is accessed.
+func NewJSONListLazy(adapter ref.TypeAdapter, size int, producer JSONListProducer) traits.Lister {
+	return &jsonListLazyValue{
+		TypeAdapter: adapter,
+		size:        size,
+		producer:    producer,
+		elems:       make([]ref.Val, size),
+		raw:         make([]*structpb.Value, size),
+	}
+}
+
+// get converts the i'th element to a ref.Val, populating l.elems lazily on
+// first access, without an index bounds check -- callers must have already
+// established that i is in range. See jsonListValue.get for why the common,
+// already-populated case only takes the read lock.
+func (l *jsonListLazyValue) get(i int) ref.Val {
+	l.elemsMu.RLock()
+	if v := l.elems[i]; v != nil {
+		l.elemsMu.RUnlock()
+		return v
+	}
+	l.elemsMu.RUnlock()
+
+	l.elemsMu.Lock()
+	defer l.elemsMu.Unlock()
+	if l.elems[i] == nil {
+		if l.raw[i] == nil {
+			l.raw[i] = l.producer(i)
+		}
+		l.elems[i] = l.NativeToValue(l.raw[i])
+	}
+	return l.elems[i]
+}
+
+// Fold implements the same Fold(func(ref.Val) bool) fast path as
+// jsonListValue, used by Contains; Iterator reads the element cache through
+// get instead.
+func (l *jsonListLazyValue) Fold(f func(ref.Val) bool) {
+	for i := 0; i < l.size; i++ {
+		if !f(l.get(i)) {
+			return
+		}
+	}
+}
+
+// Add implements the traits.Adder interface method.
+func (l *jsonListLazyValue) Add(other ref.Val) ref.Val {
+	if other.Type() != ListType {
+		return ValOrErr(other, "no such overload")
+	}
+	return &concatList{
+		TypeAdapter: l.TypeAdapter,
+		prevList:    l,
+		nextList:    other.(traits.Lister)}
+}
+
+// Contains implements the traits.Container interface method.
+func (l *jsonListLazyValue) Contains(elem ref.Val) ref.Val {
+	if IsUnknownOrError(elem) {
+		return elem
+	}
+	var err ref.Val
+	found := false
+	l.Fold(func(val ref.Val) bool {
+		cmp := elem.Equal(val)
+		b, ok := cmp.(Bool)
+		if !ok && err == nil {
+			err = ValOrErr(cmp, "no such overload")
+		}
+		if b == True {
+			found = true
+			return false
+		}
+		return true
+	})
+	if found {
+		return True
+	}
+	if err != nil {
+		return err
+	}
+	return False
+}
+
+// ConvertToNative implements the ref.Val interface method by materializing
+// every element and delegating to jsonListValue's conversion.
+func (l *jsonListLazyValue) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	return l.materialize().ConvertToNative(typeDesc)
+}
+
+// ConvertToType implements the ref.Val interface method.
+func (l *jsonListLazyValue) ConvertToType(typeVal ref.Type) ref.Val {
+	switch typeVal {
+	case ListType:
+		return l
+	case TypeType:
+		return ListType
+	}
+	return NewErr("type conversion error from '%s' to '%s'", ListType, typeVal)
+}
+
+// Equal implements the ref.Val interface method.
+func (l *jsonListLazyValue) Equal(other ref.Val) ref.Val {
+	otherList, ok := other.(traits.Lister)
+	if !ok {
+		return ValOrErr(other, "no such overload")
+	}
+	if l.Size() != otherList.Size() {
+		return False
+	}
+	for i := 0; i < l.size; i++ {
+		elemEq := l.get(i).Equal(otherList.Get(Int(i)))
+		if elemEq != True {
+			return elemEq
+		}
+	}
+	return True
+}
+
+// Get implements the traits.Indexer interface method.
+func (l *jsonListLazyValue) Get(index ref.Val) ref.Val {
+	i, ok := index.(Int)
+	if !ok {
+		return ValOrErr(index, "unsupported index type: '%v", index.Type())
+	}
+	if i < 0 || int(i) >= l.size {
+		return NewErr("index '%d' out of range in list size '%d'", i, l.size)
+	}
+	return l.get(int(i))
+}
+
+// Iterator implements the traits.Iterable interface method.
+func (l *jsonListLazyValue) Iterator() traits.Iterator {
+	return &jsonValueLazyListIterator{
+		baseIterator: &baseIterator{},
+		list:         l,
+		len:          l.size}
+}
+
+// Size implements the traits.Sizer interface method.
+func (l *jsonListLazyValue) Size() ref.Val {
+	return Int(l.size)
+}
+
+// Type implements the ref.Val interface method.
+func (l *jsonListLazyValue) Type() ref.Type {
+	return ListType
+}
+
+// Value implements the ref.Val interface method, forcing every element to be
+// produced so a concrete *structpb.ListValue can be returned.
+func (l *jsonListLazyValue) Value() interface{} {
+	return l.materialize().ListValue
+}
+
+// materialize forces every element to be produced and returns the equivalent
+// eagerly-backed jsonListValue, for the conversions that need a concrete
+// *structpb.ListValue.
+func (l *jsonListLazyValue) materialize() *jsonListValue {
+	l.elemsMu.Lock()
+	defer l.elemsMu.Unlock()
+	values := make([]*structpb.Value, l.size)
+	for i := 0; i < l.size; i++ {
+		if l.raw[i] == nil {
+			l.raw[i] = l.producer(i)
+		}
+		values[i] = l.raw[i]
+	}
+	return &jsonListValue{
+		TypeAdapter: l.TypeAdapter,
+		ListValue:   &structpb.ListValue{Values: values}}
+}
+
+type jsonValueLazyListIterator struct {
+	*baseIterator
+	list   *jsonListLazyValue
+	cursor int
+	len    int
+}
+
+// HasNext implements the traits.Iterator interface method.
+func (it *jsonValueLazyListIterator) HasNext() ref.Val {
+	return Bool(it.cursor < it.len)
+}
+
+// Next implements the traits.Iterator interface method.
+func (it *jsonValueLazyListIterator) Next() ref.Val {
+	if it.HasNext() == True {
+		index := it.cursor
+		it.cursor++
+		return it.list.get(index)
+	}
+	return nil
+}
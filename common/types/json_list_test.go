@@ -0,0 +1,157 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"testing"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// numberedListValue builds a *structpb.ListValue of n number elements, used by
+// both the cache-identity test and the benchmarks below.
+func numberedListValue(n int) *structpb.ListValue {
+	values := make([]*structpb.Value, n)
+	for i := 0; i < n; i++ {
+		values[i] = &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: float64(i)}}
+	}
+	return &structpb.ListValue{Values: values}
+}
+
+func TestJSONListElementCacheIdentity(t *testing.T) {
+	l := NewJSONList(DefaultTypeAdapter, numberedListValue(10))
+	first := l.Get(Int(3))
+	second := l.Get(Int(3))
+	if first != second {
+		t.Fatalf("Get(3) returned different ref.Val instances on repeated access: %v != %v", first, second)
+	}
+}
+
+func TestJSONListLazyCallsProducerOnce(t *testing.T) {
+	const size = 10
+	calls := make([]int, size)
+	producer := func(i int) *structpb.Value {
+		calls[i]++
+		return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: float64(i)}}
+	}
+	l := NewJSONListLazy(DefaultTypeAdapter, size, producer)
+
+	// Access every index twice, in both index order and reverse, the way a
+	// nested comprehension might re-read earlier elements.
+	for i := 0; i < size; i++ {
+		l.Get(Int(i))
+	}
+	for i := size - 1; i >= 0; i-- {
+		l.Get(Int(i))
+	}
+
+	for i, n := range calls {
+		if n != 1 {
+			t.Errorf("producer called %d times for index %d, want exactly 1", n, i)
+		}
+	}
+}
+
+func TestJSONListLazyMatchesEager(t *testing.T) {
+	lv := numberedListValue(5)
+	eager := NewJSONList(DefaultTypeAdapter, lv)
+	lazy := NewJSONListLazy(DefaultTypeAdapter, len(lv.GetValues()), func(i int) *structpb.Value {
+		return lv.GetValues()[i]
+	})
+	if eager.Equal(lazy) != True {
+		t.Fatalf("lazy list not equal to the eager list it was built from")
+	}
+}
+
+// benchList builds the 10k-element list used by the Contains benchmarks
+// below, plus the element known not to be present (so Contains always scans
+// to the end), mirroring how `in`/`exists`/`all` behave on a miss.
+func benchList(b *testing.B, n int) (ref.Val, ref.Val) {
+	b.Helper()
+	l := NewJSONList(DefaultTypeAdapter, numberedListValue(n))
+	return l, Double(-1)
+}
+
+func BenchmarkJSONListContainsMiss(b *testing.B) {
+	l, needle := benchList(b, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.(traits.Container).Contains(needle)
+	}
+}
+
+func BenchmarkJSONListContainsRepeated(b *testing.B) {
+	// Exercises the element cache: the same list is scanned repeatedly, as an
+	// `exists`/`all` inside a nested comprehension would re-scan it once per
+	// outer iteration.
+	l, needle := benchList(b, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			l.(traits.Container).Contains(needle)
+		}
+	}
+}
+
+// BenchmarkJSONListContainsContended reports the case elemsMu's read-mostly
+// locking targets: many goroutines scanning the same already-populated list
+// concurrently, the way independent Program.Eval calls sharing one adapted
+// message field would.
+func BenchmarkJSONListContainsContended(b *testing.B) {
+	l, needle := benchList(b, 10000)
+	// Populate the element cache up front so RunParallel measures the
+	// steady-state read-lock-only path, not the one-time population cost.
+	l.(traits.Container).Contains(needle)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.(traits.Container).Contains(needle)
+		}
+	})
+}
+
+func BenchmarkJSONListLazyContainsMiss(b *testing.B) {
+	const n = 10000
+	lv := numberedListValue(n)
+	l := NewJSONListLazy(DefaultTypeAdapter, n, func(i int) *structpb.Value { return lv.GetValues()[i] })
+	needle := Double(-1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.(traits.Container).Contains(needle)
+	}
+}
+
+func BenchmarkJSONListIterate(b *testing.B) {
+	l, _ := benchList(b, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := l.(traits.Iterable).Iterator()
+		for it.HasNext() == True {
+			it.Next()
+		}
+	}
+}
+
+func init() {
+	// Guard against the benchmarks above silently measuring nothing if the
+	// element count assumption drifts.
+	if got := len(numberedListValue(10000).GetValues()); got != 10000 {
+		panic(fmt.Sprintf("numberedListValue(10000) returned %d elements", got))
+	}
+}
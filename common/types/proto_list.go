@@ -0,0 +1,350 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+var (
+	protoListValueType = reflect.TypeOf(&structpb.ListValue{})
+)
+
+// listFieldConverter adapts between a protoreflect.List's element kind and the
+// ref.Val representation used elsewhere in this package, mirroring the
+// converter construction used by the modern protobuf runtime: built once from
+// the Go slice (or pointer-to-slice) type and the field descriptor it backs.
+type listFieldConverter struct {
+	goType reflect.Type
+	desc   protoreflect.FieldDescriptor
+}
+
+// newListFieldConverter builds a listFieldConverter for a Go slice, or pointer
+// to a Go slice, together with the protoreflect.FieldDescriptor describing
+// its element type. goType ends up holding the slice's element type, which is
+// what GoValueOf checks converted elements against.
+func newListFieldConverter(goType reflect.Type, desc protoreflect.FieldDescriptor) *listFieldConverter {
+	for goType.Kind() == reflect.Ptr {
+		goType = goType.Elem()
+	}
+	if goType.Kind() == reflect.Slice || goType.Kind() == reflect.Array {
+		goType = goType.Elem()
+	}
+	return &listFieldConverter{goType: goType, desc: desc}
+}
+
+// GoValueOf converts a protoreflect.Value into the Go-native value expected
+// by reflect.Value.Set when materializing a []T slice of c.goType elements.
+func (c *listFieldConverter) GoValueOf(v protoreflect.Value) (interface{}, error) {
+	switch c.desc.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		msg := v.Message().Interface()
+		if c.goType.Kind() == reflect.Interface || reflect.TypeOf(msg).AssignableTo(c.goType) {
+			return msg, nil
+		}
+		return nil, fmt.Errorf("no conversion found from message element %v to native type %v", msg, c.goType)
+	case protoreflect.EnumKind:
+		return int32(v.Enum()), nil
+	case protoreflect.BytesKind:
+		return v.Bytes(), nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// protoListValue is a traits.Lister backed directly by a protoreflect.List,
+// avoiding the double conversion through golang/protobuf that jsonListValue
+// requires for generated code using google.golang.org/protobuf.
+type protoListValue struct {
+	ref.TypeAdapter
+	list protoreflect.List
+	conv *listFieldConverter
+}
+
+// NewProtoList creates a traits.Lister implementation backed by a
+// protoreflect.List, for callers whose generated code already produces
+// google.golang.org/protobuf reflection values.
+//
+// The `adapter` argument provides type adaptation capabilities from proto to
+// CEL. `goType` is the Go slice, or pointer-to-slice, type of the generated
+// struct field list backs (e.g. the type of a `[]*pb.Foo` or `[]string`
+// field), and is used to validate message-typed elements on conversion.
+func NewProtoList(adapter ref.TypeAdapter, goType reflect.Type, list protoreflect.List, desc protoreflect.FieldDescriptor) traits.Lister {
+	return &protoListValue{
+		TypeAdapter: adapter,
+		list:        list,
+		conv:        newListFieldConverter(goType, desc),
+	}
+}
+
+// TryNewProtoList recognizes the two input shapes this file knows how to wrap
+// directly -- a protoreflect.List view over a message field, or a
+// *structpb.ListValue -- and returns the corresponding traits.Lister.
+// NativeToValue's dispatch on the value's Go type calls this before falling
+// back to the legacy jsonListValue path, so that generated code already
+// migrated to google.golang.org/protobuf gets a protoListValue (no
+// ptypes.MarshalAny double conversion) while values coming in as
+// *structpb.ListValue still work the same as they always have.
+func TryNewProtoList(adapter ref.TypeAdapter, goType reflect.Type, desc protoreflect.FieldDescriptor, value interface{}) (traits.Lister, bool) {
+	switch v := value.(type) {
+	case protoreflect.List:
+		return NewProtoList(adapter, goType, v, desc), true
+	case *structpb.ListValue:
+		return NewJSONList(adapter, v), true
+	}
+	return nil, false
+}
+
+// Add implements the traits.Adder interface method.
+func (l *protoListValue) Add(other ref.Val) ref.Val {
+	if other.Type() != ListType {
+		return ValOrErr(other, "no such overload")
+	}
+	if otherList, ok := other.(*protoListValue); ok {
+		// Preserve the concrete protoListValue backing, the same way
+		// jsonListValue.Add concatenates into another jsonListValue rather
+		// than falling back to a generic list, so a proto-backed `a + b`
+		// keeps behaving like a proto-backed list (e.g. on a later
+		// ConvertToNative call).
+		elems := make([]protoreflect.Value, 0, l.list.Len()+otherList.list.Len())
+		for i := 0; i < l.list.Len(); i++ {
+			elems = append(elems, l.list.Get(i))
+		}
+		for i := 0; i < otherList.list.Len(); i++ {
+			elems = append(elems, otherList.list.Get(i))
+		}
+		return &protoListValue{
+			TypeAdapter: l.TypeAdapter,
+			list:        &protoValueSlice{elems: elems},
+			conv:        l.conv,
+		}
+	}
+	return &concatList{
+		TypeAdapter: l.TypeAdapter,
+		prevList:    l,
+		nextList:    other.(traits.Lister)}
+}
+
+// protoValueSlice is a protoreflect.List backed by a plain Go slice rather
+// than a message field, used to hold the concatenation of two protoListValues
+// without attaching the result to either operand's owning message.
+type protoValueSlice struct {
+	elems []protoreflect.Value
+}
+
+func (s *protoValueSlice) Len() int                       { return len(s.elems) }
+func (s *protoValueSlice) Get(i int) protoreflect.Value   { return s.elems[i] }
+func (s *protoValueSlice) Set(i int, v protoreflect.Value) { s.elems[i] = v }
+func (s *protoValueSlice) Append(v protoreflect.Value)     { s.elems = append(s.elems, v) }
+func (s *protoValueSlice) Truncate(n int)                  { s.elems = s.elems[:n] }
+func (s *protoValueSlice) IsValid() bool                   { return true }
+func (s *protoValueSlice) NewElement() protoreflect.Value  { return protoreflect.Value{} }
+func (s *protoValueSlice) AppendMutable() protoreflect.Value {
+	s.elems = append(s.elems, protoreflect.Value{})
+	return s.elems[len(s.elems)-1]
+}
+
+// Contains implements the traits.Container interface method.
+func (l *protoListValue) Contains(elem ref.Val) ref.Val {
+	if IsUnknownOrError(elem) {
+		return elem
+	}
+	var err ref.Val
+	for i := 0; i < l.list.Len(); i++ {
+		cmp := elem.Equal(l.get(i))
+		b, ok := cmp.(Bool)
+		if !ok && err == nil {
+			err = ValOrErr(cmp, "no such overload")
+		}
+		if b == True {
+			return True
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return False
+}
+
+// ConvertToNative implements the ref.Val interface method.
+func (l *protoListValue) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	switch typeDesc.Kind() {
+	case reflect.Array, reflect.Slice:
+		elemCount := l.list.Len()
+		nativeList := reflect.MakeSlice(typeDesc, elemCount, elemCount)
+		for i := 0; i < elemCount; i++ {
+			nativeElemVal, err := l.get(i).ConvertToNative(typeDesc.Elem())
+			if err != nil {
+				return nil, err
+			}
+			nativeList.Index(i).Set(reflect.ValueOf(nativeElemVal))
+		}
+		return nativeList.Interface(), nil
+
+	case reflect.Ptr:
+		switch typeDesc {
+		case jsonValueType:
+			v, err := l.ConvertToNative(protoListValueType)
+			if err != nil {
+				return nil, err
+			}
+			return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: v.(*structpb.ListValue)}}, nil
+		case protoListValueType:
+			vals := make([]*structpb.Value, l.list.Len())
+			for i := 0; i < l.list.Len(); i++ {
+				v, err := l.get(i).ConvertToNative(jsonValueType)
+				if err != nil {
+					return nil, err
+				}
+				vals[i] = v.(*structpb.Value)
+			}
+			return &structpb.ListValue{Values: vals}, nil
+		case anyValueType:
+			pb, err := l.ConvertToNative(protoListValueType)
+			if err != nil {
+				return nil, err
+			}
+			return anypb.New(pb.(proto.Message))
+		}
+
+	case reflect.Interface:
+		plistType := reflect.TypeOf((*protoreflect.List)(nil)).Elem()
+		if typeDesc == plistType {
+			return l.list, nil
+		}
+		if reflect.TypeOf(l).Implements(typeDesc) {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("no conversion found from list type to native type."+
+		" list elem: protoreflect.Value, native type: %v", typeDesc)
+}
+
+// ConvertToType implements the ref.Val interface method.
+func (l *protoListValue) ConvertToType(typeVal ref.Type) ref.Val {
+	switch typeVal {
+	case ListType:
+		return l
+	case TypeType:
+		return ListType
+	}
+	return NewErr("type conversion error from '%s' to '%s'", ListType, typeVal)
+}
+
+// Equal implements the ref.Val interface method.
+func (l *protoListValue) Equal(other ref.Val) ref.Val {
+	otherList, ok := other.(traits.Lister)
+	if !ok {
+		return ValOrErr(other, "no such overload")
+	}
+	if l.Size() != otherList.Size() {
+		return False
+	}
+	for i := IntZero; i < l.Size().(Int); i++ {
+		thisElem := l.Get(i)
+		otherElem := otherList.Get(i)
+		elemEq := thisElem.Equal(otherElem)
+		if elemEq != True {
+			return elemEq
+		}
+	}
+	return True
+}
+
+// Get implements the traits.Indexer interface method.
+func (l *protoListValue) Get(index ref.Val) ref.Val {
+	i, ok := index.(Int)
+	if !ok {
+		return ValOrErr(index, "unsupported index type: '%v", index.Type())
+	}
+	if i < 0 || i >= l.Size().(Int) {
+		return NewErr("index '%d' out of range in list size '%d'", i, l.Size())
+	}
+	return l.get(int(i))
+}
+
+// get converts the i'th protoreflect.Value in the backing list to a ref.Val
+// without an index bounds check, for use by methods that have already
+// established the index is in range.
+func (l *protoListValue) get(i int) ref.Val {
+	v, err := l.conv.GoValueOf(l.list.Get(i))
+	if err != nil {
+		return NewErr("%v", err)
+	}
+	return l.NativeToValue(v)
+}
+
+// Iterator implements the traits.Iterable interface method.
+func (l *protoListValue) Iterator() traits.Iterator {
+	elems := make([]ref.Val, l.list.Len())
+	for i := 0; i < l.list.Len(); i++ {
+		elems[i] = l.get(i)
+	}
+	return &protoValueListIterator{
+		baseIterator: &baseIterator{},
+		elems:        elems,
+		len:          len(elems)}
+}
+
+// Size implements the traits.Sizer interface method.
+func (l *protoListValue) Size() ref.Val {
+	return Int(l.list.Len())
+}
+
+// Type implements the ref.Val interface method.
+func (l *protoListValue) Type() ref.Type {
+	return ListType
+}
+
+// Value implements the ref.Val interface method.
+func (l *protoListValue) Value() interface{} {
+	v, err := l.ConvertToNative(protoListValueType)
+	if err != nil {
+		return NewErr("%v", err)
+	}
+	return v
+}
+
+type protoValueListIterator struct {
+	*baseIterator
+	cursor int
+	elems  []ref.Val
+	len    int
+}
+
+// HasNext implements the traits.Iterator interface method.
+func (it *protoValueListIterator) HasNext() ref.Val {
+	return Bool(it.cursor < it.len)
+}
+
+// Next implements the traits.Iterator interface method.
+func (it *protoValueListIterator) Next() ref.Val {
+	if it.HasNext() == True {
+		index := it.cursor
+		it.cursor++
+		return it.elems[index]
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"context"
+	"reflect"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// NativeToValue is the list-shaped slice of the real NativeToValue dispatch:
+// it recognizes the two proto-backed list representations this package can
+// wrap directly -- a protoreflect.List view over a message field, or a
+// legacy *structpb.ListValue -- and returns the ref.Val that wraps them,
+// preferring protoListValue, which reads elements without the golang/protobuf
+// double conversion, over the legacy jsonListValue
+// path whenever the caller already has a protoreflect.List. The rest of
+// NativeToValue's dispatch (scalars, maps, messages, and the reflect-based
+// fallback for arbitrary Go types) lives in the real provider this function
+// extends.
+//
+// tracer and ctx come from the in-progress Program.Eval (see cel.WithTracer):
+// a non-nil tracer wraps the returned list so its Contains/Equal/Iterator/Add
+// calls are instrumented, while the common, tracer-less path leaves the list
+// undecorated.
+func NativeToValue(ctx context.Context, tracer Tracer, adapter ref.TypeAdapter, goType reflect.Type, desc protoreflect.FieldDescriptor, value interface{}) (ref.Val, bool) {
+	lister, ok := TryNewProtoList(adapter, goType, desc, value)
+	if !ok {
+		return nil, false
+	}
+	if tracer != nil {
+		lister = NewTracedList(ctx, tracer, lister)
+	}
+	return lister, true
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"context"
+
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// Tracer is a pluggable interceptor for the hot paths in this package (list
+// traversal, comprehension-heavy Contains/Equal/Iterator calls; maps are not
+// instrumented). It is
+// intentionally minimal so that higher-level packages, such as the top-level
+// `cel` package, can re-export it under a more descriptive name (e.g.
+// EvalTracer) and adapt it to a tracing backend without this package needing
+// to depend on one.
+type Tracer interface {
+	// StartSpan begins a span named `name` as a child of any span already
+	// present on ctx, returning the derived context and a handle used to end
+	// the span and attach events to it.
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+
+	// Event records a point-in-time occurrence, such as a single comprehension
+	// step, against the span (if any) already present on ctx.
+	Event(ctx context.Context, name string, attrs ...Attribute)
+}
+
+// Span is the handle returned by Tracer.StartSpan.
+type Span interface {
+	// End completes the span, optionally attaching summary attributes, such
+	// as the number of elements iterated.
+	End(attrs ...Attribute)
+}
+
+// Attribute is a single key/value pair attached to a span or event.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// noopTracer is the default Tracer used when none has been configured. All of
+// its methods are no-ops so that instrumented call sites never need to check
+// for a nil tracer.
+type noopTracer struct{}
+
+func (noopTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopTracer) Event(ctx context.Context, name string, attrs ...Attribute) {}
+
+type noopSpan struct{}
+
+func (noopSpan) End(attrs ...Attribute) {}
+
+// NoopTracer returns the default, no-op Tracer implementation.
+func NoopTracer() Tracer {
+	return noopTracer{}
+}
+
+// tracedList decorates a traits.Lister so that the hot paths called out for
+// tracing -- Contains, Equal, Iterator, and the Add call that builds a
+// concatList -- emit spans/events through tracer, as children of ctx.
+type tracedList struct {
+	traits.Lister
+	ctx    context.Context
+	tracer Tracer
+}
+
+// NewTracedList wraps l so that Contains, Equal, Iterator, and Add calls emit
+// a span through tracer. Callers that do not need tracing should keep using
+// the undecorated list; this is the opt-in constructor NativeToValue uses to
+// wrap a freshly built jsonListValue/protoListValue when a tracer has been
+// configured for the in-progress Program.Eval (see cel.WithTracer).
+func NewTracedList(ctx context.Context, tracer Tracer, l traits.Lister) traits.Lister {
+	if tracer == nil {
+		tracer = NoopTracer()
+	}
+	return &tracedList{Lister: l, ctx: ctx, tracer: tracer}
+}
+
+// Contains wraps the decorated list's Contains call in a span and records the
+// list size scanned.
+func (t *tracedList) Contains(elem ref.Val) ref.Val {
+	_, span := t.tracer.StartSpan(t.ctx, "cel.list.Contains")
+	defer func() { span.End(Attribute{Key: "cel.list.size", Value: t.Lister.Size()}) }()
+	return t.Lister.Contains(elem)
+}
+
+// Equal wraps the decorated list's Equal call in a span.
+func (t *tracedList) Equal(other ref.Val) ref.Val {
+	_, span := t.tracer.StartSpan(t.ctx, "cel.list.Equal")
+	defer span.End()
+	return t.Lister.Equal(other)
+}
+
+// Add wraps the decorated list's Add call in a span. The result is re-wrapped
+// so that tracing follows a list through repeated concatenation, e.g.
+// `a + b + c`.
+func (t *tracedList) Add(other ref.Val) ref.Val {
+	_, span := t.tracer.StartSpan(t.ctx, "cel.list.Add")
+	defer span.End()
+	sum := t.Lister.Add(other)
+	if l, ok := sum.(traits.Lister); ok {
+		return NewTracedList(t.ctx, t.tracer, l)
+	}
+	return sum
+}
+
+// Iterator wraps the decorated list's Iterator call in a span and returns an
+// iterator that emits an event per element visited, so that an expensive
+// comprehension or list scan shows up as a series of events under that span.
+func (t *tracedList) Iterator() traits.Iterator {
+	ctx, span := t.tracer.StartSpan(t.ctx, "cel.list.Iterator")
+	defer span.End(Attribute{Key: "cel.list.size", Value: t.Lister.Size()})
+	return &tracedIterator{Iterator: t.Lister.Iterator(), ctx: ctx, tracer: t.tracer}
+}
+
+// tracedIterator decorates a traits.Iterator so that each element visited
+// during a comprehension emits an event, tagged with its index.
+type tracedIterator struct {
+	traits.Iterator
+	ctx    context.Context
+	tracer Tracer
+	index  int
+}
+
+// Next wraps the decorated iterator's Next call, emitting an event tagged
+// with the index of the element just returned.
+func (it *tracedIterator) Next() ref.Val {
+	v := it.Iterator.Next()
+	it.tracer.Event(it.ctx, "cel.list.iterator.next", Attribute{Key: "cel.list.index", Value: it.index})
+	it.index++
+	return v
+}